@@ -0,0 +1,144 @@
+package dejavu
+
+import (
+	"crypto/sha256"
+	"sync"
+)
+
+//////////////////////////////////
+// Deterministic implementation //
+//////////////////////////////////
+
+// core is the ring-buffer implementation shared by every deterministic
+// backend, parameterized over the digest type D so that the hash function
+// (and its output size) is pluggable rather than hard-coded to SHA-256.
+type core[D comparable] struct {
+	buffer []D       // ring buffer
+	size   int       // ring buffer size
+	index  int       // current ring buffer index
+	lookup map[D]int // digest -> newest index (optimization)
+	hasher func([]byte) D
+	mutex  *sync.Mutex
+
+	hits, misses, evictions uint64 // Stats counters
+	hashAlgo                hashID // identifies hasher for Snapshot; hashCustom if set via WithHasher
+}
+
+// Option configures a deterministic DejaVu backend at construction time.
+type Option[D comparable] func(*core[D])
+
+// WithHasher overrides the hash function used by Witness to digest data,
+// letting callers substitute BLAKE3, xxhash or another digest for the
+// default. WitnessDigest is unaffected since it already takes a digest.
+func WithHasher[D comparable](hasher func([]byte) D) Option[D] {
+	return func(c *core[D]) {
+		c.hasher = hasher
+		c.hashAlgo = hashCustom // Snapshot cannot recover an arbitrary hasher
+	}
+}
+
+func newCore[D comparable](entrieLimit uint, hasher func([]byte) D, algo hashID, opts ...Option[D]) *core[D] {
+	c := &core[D]{
+		buffer:   make([]D, entrieLimit),
+		size:     int(entrieLimit),
+		index:    0,
+		lookup:   make(map[D]int),
+		hasher:   hasher,
+		hashAlgo: algo,
+		mutex:    new(sync.Mutex),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// NewDejaVuDeterministic creates a deterministic DejaVu memory. Will remember
+// most recent entries within given entrie limit and forget older entries.
+// By default digests are computed with SHA-256; pass WithHasher to use a
+// different hash function.
+func NewDejaVuDeterministic(entrieLimit uint, opts ...Option[[sha256.Size]byte]) DejaVu {
+	return newCore(entrieLimit, sha256.Sum256, hashSHA256, opts...)
+}
+
+// NewDejaVuDeterministicSHA256 is equivalent to NewDejaVuDeterministic and
+// is provided for symmetry with NewDejaVuDeterministicBLAKE3 and
+// NewDejaVuDeterministicXXH128.
+func NewDejaVuDeterministicSHA256(entrieLimit uint) DejaVu {
+	return NewDejaVuDeterministic(entrieLimit)
+}
+
+func (c *core[D]) WitnessDigest(dataDigest D) bool {
+	c.mutex.Lock()
+	_, familiar := c.lookup[dataDigest] // check if previously seen
+	if familiar {
+		c.hits++
+	} else {
+		c.misses++
+	}
+
+	// rm oldest lookup key if no newer entry
+	maxed := len(c.buffer) == c.size // overwriting oldest entry
+	if newest, ok := c.lookup[c.buffer[c.index]]; maxed && ok && newest == c.index {
+		delete(c.lookup, c.buffer[c.index]) // no newer entries
+		c.evictions++
+	}
+
+	// add entry and update index/lookup
+	c.buffer[c.index] = dataDigest
+	c.lookup[dataDigest] = c.index
+	c.index = (c.index + 1) % c.size
+
+	c.mutex.Unlock()
+	return familiar
+}
+
+// Stats returns a snapshot of this backend's hit/miss/eviction counters.
+func (c *core[D]) Stats() Stats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return Stats{
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+		Size:      uint64(len(c.lookup)),
+		Capacity:  uint64(c.size),
+	}
+}
+
+func (c *core[D]) Witness(data []byte) bool {
+	return c.WitnessDigest(c.hasher(data))
+}
+
+func (c *core[D]) WitnessDigestBatch(digests []D) []bool {
+	familiar := make([]bool, len(digests))
+
+	c.mutex.Lock()
+	for i, digest := range digests {
+		_, seen := c.lookup[digest]
+		if seen {
+			c.hits++
+		} else {
+			c.misses++
+		}
+
+		maxed := len(c.buffer) == c.size
+		if newest, ok := c.lookup[c.buffer[c.index]]; maxed && ok && newest == c.index {
+			delete(c.lookup, c.buffer[c.index])
+			c.evictions++
+		}
+
+		c.buffer[c.index] = digest
+		c.lookup[digest] = c.index
+		c.index = (c.index + 1) % c.size
+
+		familiar[i] = seen
+	}
+	c.mutex.Unlock()
+
+	return familiar
+}
+
+func (c *core[D]) WitnessBatch(data [][]byte) []bool {
+	return c.WitnessDigestBatch(hashBatch(data, c.hasher))
+}
@@ -0,0 +1,47 @@
+package dejavu
+
+import "testing"
+
+// TestWitnessDigestBatchMatchesSequentialCalls checks that
+// WitnessDigestBatch on the deterministic backend reports the same
+// familiarity, in order, as issuing the same digests one WitnessDigest
+// call at a time.
+func TestWitnessDigestBatchMatchesSequentialCalls(t *testing.T) {
+	sequence := []int{1, 2, 3, 1, 4, 5, 2}
+
+	sequential := NewDejaVuDeterministic(3)
+	var wantFamiliar []bool
+	for _, n := range sequence {
+		wantFamiliar = append(wantFamiliar, sequential.WitnessDigest(digestFor(n)))
+	}
+
+	batched := NewDejaVuDeterministic(3)
+	digests := make([][32]byte, len(sequence))
+	for i, n := range sequence {
+		digests[i] = digestFor(n)
+	}
+	gotFamiliar := batched.WitnessDigestBatch(digests)
+
+	for i := range sequence {
+		if gotFamiliar[i] != wantFamiliar[i] {
+			t.Errorf("index %d (digest %d): batch familiar=%v, sequential familiar=%v", i, sequence[i], gotFamiliar[i], wantFamiliar[i])
+		}
+	}
+}
+
+// TestWitnessBatchHashesAndWitnessesEveryEntry checks that WitnessBatch
+// hashes each entry in data and witnesses it, the same as calling Witness
+// once per entry, rather than dropping or reordering any of them.
+func TestWitnessBatchHashesAndWitnessesEveryEntry(t *testing.T) {
+	dv := NewDejaVuDeterministic(10)
+
+	data := [][]byte{[]byte("a"), []byte("b"), []byte("a"), []byte("c")}
+	familiar := dv.WitnessBatch(data)
+
+	want := []bool{false, false, true, false}
+	for i := range data {
+		if familiar[i] != want[i] {
+			t.Errorf("entry %d (%q): familiar=%v, want %v", i, data[i], familiar[i], want[i])
+		}
+	}
+}
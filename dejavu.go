@@ -2,64 +2,69 @@ package dejavu
 
 import (
 	"crypto/sha256"
+	"runtime"
 	"sync"
 )
 
-// DejaVu witnesses data and recalls if seen before.
-type DejaVu interface {
+// DejaVuG is the digest-generic counterpart of DejaVu. A backend configured
+// with a 32-byte hash (the default SHA-256, or BLAKE3 via WithHasher)
+// implements DejaVuG[[32]byte], i.e. DejaVu itself; a backend configured
+// with a 16-byte hash (XXH128) implements DejaVuG[[16]byte] instead, since
+// WitnessDigest's parameter type follows the digest size.
+type DejaVuG[D comparable] interface {
 
 	// Witness data and add to memory. Returns true if previously seen.
 	Witness(data []byte) bool
 
 	// WitnessDigest is equivalent to the Winness method but bypasses hashing
 	// the data. Use this to improve performance if you already happen
-	// to have the sha256 digest.
-	WitnessDigest(dataDigest [sha256.Size]byte) bool
-}
+	// to have the digest.
+	WitnessDigest(dataDigest D) bool
 
-//////////////////////////////////
-// Deterministic implementation //
-//////////////////////////////////
+	// WitnessBatch is equivalent to calling Witness once per entry in data,
+	// but amortizes locking and hashing overhead across the whole batch.
+	// The returned slice has one bool per entry in data, in the same order.
+	WitnessBatch(data [][]byte) []bool
 
-type deterministic struct {
-	buffer [][sha256.Size]byte       // ring buffer
-	size   int                       // ring buffer size
-	index  int                       // current ring buffer index
-	lookup map[[sha256.Size]byte]int // digest -> newest index (optimization)
-	mutex  *sync.Mutex
+	// WitnessDigestBatch is equivalent to calling WitnessDigest once per
+	// entry in digests, but takes the backend's lock only once for the
+	// whole batch. The returned slice has one bool per entry in digests, in
+	// the same order.
+	WitnessDigestBatch(digests []D) []bool
 }
 
-// NewDejaVuDeterministic creates a deterministic DejaVu memory. Will remember
-// most recent entries within given entrie limit and forget older entries.
-func NewDejaVuDeterministic(entrieLimit uint) DejaVu {
-	return &deterministic{
-		buffer: make([][sha256.Size]byte, entrieLimit),
-		size:   int(entrieLimit),
-		index:  0,
-		lookup: make(map[[sha256.Size]byte]int),
-		mutex:  new(sync.Mutex),
-	}
-}
+// DejaVu witnesses data and recalls if seen before, using the default
+// 32-byte digest.
+type DejaVu = DejaVuG[[sha256.Size]byte]
 
-func (d *deterministic) WitnessDigest(dataDigest [sha256.Size]byte) bool {
-	d.mutex.Lock()
-	_, familiar := d.lookup[dataDigest] // check if previously seen
+// hashBatch hashes data concurrently, using a worker pool sized to
+// GOMAXPROCS, returning one digest per entry in data, in the same order.
+func hashBatch[D comparable](data [][]byte, hasher func([]byte) D) []D {
+	digests := make([]D, len(data))
 
-	// rm oldest lookup key if no newer entry
-	maxed := len(d.buffer) == d.size // overwriting oldest entry
-	if maxed && (d.lookup[d.buffer[d.index]] == d.index) {
-		delete(d.lookup, d.buffer[d.index]) // no newer entries
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(data) {
+		workers = len(data)
 	}
 
-	// add entry and update index/lookup
-	d.buffer[d.index] = dataDigest
-	d.lookup[dataDigest] = d.index
-	d.index = (d.index + 1) % d.size
+	var wg sync.WaitGroup
+	next := make(chan int)
 
-	d.mutex.Unlock()
-	return familiar
-}
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				digests[i] = hasher(data[i])
+			}
+		}()
+	}
+
+	for i := range data {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
 
-func (d *deterministic) Witness(data []byte) bool {
-	return d.WitnessDigest(sha256.Sum256(data))
+	return digests
 }
@@ -0,0 +1,306 @@
+package dejavu
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"sync"
+)
+
+const nilIndex = -1
+
+// exactNodeSize is the memory footprint of a single exactNode: the digest
+// plus five int32 slab indices (left, right, parent, prev, next).
+const exactNodeSize = sha256.Size + 5*4
+
+// exactNode is one slot in the exact backend's preallocated slab. left,
+// right and parent describe its place in the binary search tree keyed by
+// digest; prev and next link it into the doubly linked FIFO insertion-order
+// chain used for eviction. All five are slab indices rather than pointers,
+// so the slab needs no GC-visible pointers and no unsafe code.
+type exactNode struct {
+	digest [sha256.Size]byte
+	left   int32
+	right  int32
+	parent int32
+	prev   int32
+	next   int32
+}
+
+// exact is a DejaVu backend that remembers exactly (no false positives,
+// unlike the probabilistic/bloom backend) up to capacity digests, using a
+// fixed-capacity slab of nodes: a binary search tree over the slab for
+// O(log n) Witness/WitnessDigest, and a doubly linked FIFO chain over the
+// same slab that tracks insertion order, so the oldest-inserted digest
+// (not the least-recently-witnessed one; see lru.go for that) is evicted
+// once the slab is full. This matches the deterministic backend's ring
+// buffer only while every witnessed digest is new: on a hit the ring buffer
+// re-stamps the digest as newest, while exact leaves the chain as it is.
+// All capacity nodes are allocated up front, so there are no
+// allocations once NewDejaVuExact returns.
+type exact struct {
+	nodes    []exactNode
+	free     []int32 // stack of unused slab indices
+	freeTop  int32   // number of unused slab indices currently in free
+	root     int32
+	head     int32 // oldest (first-inserted) live node, evicted first
+	tail     int32 // newest (most-recently-inserted) live node
+	length   int32
+	capacity int32
+	mutex    *sync.Mutex
+
+	hits, misses, evictions uint64 // Stats counters
+}
+
+// NewDejaVuExact creates a DejaVu memory that remembers up to capacity
+// digests with exact membership (no false positives) while using less RAM
+// than the map-based deterministic backend. It pre-allocates all of its
+// memory up front and performs no further allocations.
+func NewDejaVuExact(capacity uint) DejaVu {
+	nodes := make([]exactNode, capacity)
+	free := make([]int32, capacity)
+	for i := range free {
+		free[i] = int32(i)
+	}
+
+	return &exact{
+		nodes:    nodes,
+		free:     free,
+		freeTop:  int32(capacity),
+		root:     nilIndex,
+		head:     nilIndex,
+		tail:     nilIndex,
+		length:   0,
+		capacity: int32(capacity),
+		mutex:    new(sync.Mutex),
+	}
+}
+
+func (e *exact) WitnessDigest(digest [sha256.Size]byte) bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.witnessDigestLocked(digest)
+}
+
+// witnessDigestLocked is the WitnessDigest body, factored out so
+// WitnessDigestBatch can take e.mutex once for the whole batch instead of
+// once per digest.
+func (e *exact) witnessDigestLocked(digest [sha256.Size]byte) bool {
+	if idx := e.search(digest); idx != nilIndex {
+		e.hits++
+		return true
+	}
+	e.misses++
+
+	if e.capacity == 0 {
+		// No slab slots were allocated, so there is nowhere to evict from
+		// (e.head is still nilIndex) or insert into; stay a no-op rather
+		// than indexing e.nodes[nilIndex] below.
+		return false
+	}
+
+	if e.length == e.capacity {
+		oldest := e.head
+		e.unlinkChain(oldest)
+		e.deleteNode(oldest)
+		e.length--
+		e.evictions++
+	}
+
+	idx := e.allocSlot()
+	e.nodes[idx] = exactNode{digest: digest, left: nilIndex, right: nilIndex, parent: nilIndex, prev: nilIndex, next: nilIndex}
+	e.insert(idx)
+	e.pushTail(idx)
+	e.length++
+
+	return false
+}
+
+func (e *exact) Witness(data []byte) bool {
+	return e.WitnessDigest(sha256.Sum256(data))
+}
+
+func (e *exact) WitnessDigestBatch(digests [][sha256.Size]byte) []bool {
+	familiar := make([]bool, len(digests))
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	for i, digest := range digests {
+		familiar[i] = e.witnessDigestLocked(digest)
+	}
+
+	return familiar
+}
+
+func (e *exact) WitnessBatch(data [][]byte) []bool {
+	// Hashing runs concurrently in hashBatch's worker pool, as in the
+	// deterministic backend; WitnessDigestBatch then takes e.mutex once for
+	// the whole batch.
+	return e.WitnessDigestBatch(hashBatch(data, sha256.Sum256))
+}
+
+// Size returns the approximate memory footprint of the backend, in bytes.
+func (e *exact) Size() uint {
+	return uint(e.capacity)*exactNodeSize + uint(e.capacity)*4
+}
+
+// Length returns the number of digests currently stored.
+func (e *exact) Length() uint {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return uint(e.length)
+}
+
+// Stats returns a snapshot of this backend's hit/miss/eviction counters.
+func (e *exact) Stats() Stats {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return Stats{
+		Hits:      e.hits,
+		Misses:    e.misses,
+		Evictions: e.evictions,
+		Size:      uint64(e.length),
+		Capacity:  uint64(e.capacity),
+	}
+}
+
+func (e *exact) search(digest [sha256.Size]byte) int32 {
+	cur := e.root
+	for cur != nilIndex {
+		switch c := bytes.Compare(digest[:], e.nodes[cur].digest[:]); {
+		case c == 0:
+			return cur
+		case c < 0:
+			cur = e.nodes[cur].left
+		default:
+			cur = e.nodes[cur].right
+		}
+	}
+	return nilIndex
+}
+
+func (e *exact) insert(idx int32) {
+	if e.root == nilIndex {
+		e.root = idx
+		e.nodes[idx].parent = nilIndex
+		return
+	}
+
+	cur := e.root
+	for {
+		if bytes.Compare(e.nodes[idx].digest[:], e.nodes[cur].digest[:]) < 0 {
+			if e.nodes[cur].left == nilIndex {
+				e.nodes[cur].left = idx
+				e.nodes[idx].parent = cur
+				return
+			}
+			cur = e.nodes[cur].left
+		} else {
+			if e.nodes[cur].right == nilIndex {
+				e.nodes[cur].right = idx
+				e.nodes[idx].parent = cur
+				return
+			}
+			cur = e.nodes[cur].right
+		}
+	}
+}
+
+// deleteNode removes targetIdx from the binary search tree in place,
+// splicing in its in-order successor when it has two children. Digest
+// bytes are never moved between slab slots, only left/right/parent
+// pointers are rewired, so targetIdx is always the slot freed.
+func (e *exact) deleteNode(targetIdx int32) {
+	node := &e.nodes[targetIdx]
+
+	switch {
+	case node.left == nilIndex && node.right == nilIndex:
+		e.replaceChild(node.parent, targetIdx, nilIndex)
+
+	case node.left == nilIndex:
+		e.replaceChild(node.parent, targetIdx, node.right)
+		e.nodes[node.right].parent = node.parent
+
+	case node.right == nilIndex:
+		e.replaceChild(node.parent, targetIdx, node.left)
+		e.nodes[node.left].parent = node.parent
+
+	default:
+		succIdx := e.min(node.right)
+		succ := &e.nodes[succIdx]
+
+		if succ.parent != targetIdx {
+			e.replaceChild(succ.parent, succIdx, succ.right)
+			if succ.right != nilIndex {
+				e.nodes[succ.right].parent = succ.parent
+			}
+			succ.right = node.right
+			e.nodes[succ.right].parent = succIdx
+		}
+
+		succ.left = node.left
+		e.nodes[succ.left].parent = succIdx
+		e.replaceChild(node.parent, targetIdx, succIdx)
+		succ.parent = node.parent
+	}
+
+	e.freeSlot(targetIdx)
+}
+
+func (e *exact) replaceChild(parentIdx, oldChildIdx, newChildIdx int32) {
+	if parentIdx == nilIndex {
+		e.root = newChildIdx
+		return
+	}
+	parent := &e.nodes[parentIdx]
+	if parent.left == oldChildIdx {
+		parent.left = newChildIdx
+	} else {
+		parent.right = newChildIdx
+	}
+}
+
+func (e *exact) min(idx int32) int32 {
+	for e.nodes[idx].left != nilIndex {
+		idx = e.nodes[idx].left
+	}
+	return idx
+}
+
+// unlinkChain splices idx out of the eviction chain without freeing it.
+func (e *exact) unlinkChain(idx int32) {
+	node := &e.nodes[idx]
+	if node.prev != nilIndex {
+		e.nodes[node.prev].next = node.next
+	} else {
+		e.head = node.next
+	}
+	if node.next != nilIndex {
+		e.nodes[node.next].prev = node.prev
+	} else {
+		e.tail = node.prev
+	}
+}
+
+// pushTail appends idx, which must not already be in the chain, as the
+// newest (most-recently-inserted) node.
+func (e *exact) pushTail(idx int32) {
+	node := &e.nodes[idx]
+	node.prev = e.tail
+	node.next = nilIndex
+	if e.tail != nilIndex {
+		e.nodes[e.tail].next = idx
+	} else {
+		e.head = idx
+	}
+	e.tail = idx
+}
+
+func (e *exact) allocSlot() int32 {
+	e.freeTop--
+	return e.free[e.freeTop]
+}
+
+func (e *exact) freeSlot(idx int32) {
+	e.free[e.freeTop] = idx
+	e.freeTop++
+}
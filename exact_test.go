@@ -0,0 +1,145 @@
+package dejavu
+
+import (
+	"crypto/sha256"
+	"math/rand"
+	"testing"
+)
+
+func digestFor(n int) [sha256.Size]byte {
+	var d [sha256.Size]byte
+	d[0] = byte(n)
+	d[1] = byte(n >> 8)
+	return d
+}
+
+// TestExactMirrorsDeterministicEvictionWithoutRepeats checks that exact
+// evicts the same digests as the deterministic ring buffer under an
+// append-only overflow workload. The two only agree while every witnessed
+// digest is new: on a hit, the deterministic ring buffer re-stamps the
+// digest's slot (moving it to the newest position), while exact leaves
+// strictly to insertion order (see
+// TestExactWitnessDigestHitDoesNotRefreshEvictionOrder), so a workload
+// with repeats is expected to diverge rather than match.
+func TestExactMirrorsDeterministicEvictionWithoutRepeats(t *testing.T) {
+	sequence := []int{1, 2, 3, 4, 5}
+
+	for _, probe := range []int{1, 2, 3, 4, 5} {
+		det := NewDejaVuDeterministic(3)
+		ex := NewDejaVuExact(3)
+		for _, n := range sequence {
+			det.WitnessDigest(digestFor(n))
+			ex.WitnessDigest(digestFor(n))
+		}
+
+		wantFamiliar := det.WitnessDigest(digestFor(probe))
+		gotFamiliar := ex.WitnessDigest(digestFor(probe))
+		if gotFamiliar != wantFamiliar {
+			t.Errorf("probe %d: deterministic familiar=%v, exact familiar=%v", probe, wantFamiliar, gotFamiliar)
+		}
+	}
+}
+
+// TestExactWitnessDigestHitDoesNotRefreshEvictionOrder verifies a hit
+// leaves the FIFO eviction chain untouched: re-witnessing digest 1 does
+// not protect it from eviction, since exact evicts strictly in insertion
+// order (unlike lru.go, which evicts least-recently-witnessed).
+func TestExactWitnessDigestHitDoesNotRefreshEvictionOrder(t *testing.T) {
+	ex := NewDejaVuExact(2).(*exact)
+
+	ex.WitnessDigest(digestFor(1))
+	ex.WitnessDigest(digestFor(2))
+	if familiar := ex.WitnessDigest(digestFor(1)); !familiar {
+		t.Fatalf("expected digest 1 to be familiar")
+	}
+
+	// Capacity is 2; digest 1 is still the oldest insertion despite the
+	// hit above, so inserting a third digest must evict digest 1, not 2.
+	ex.WitnessDigest(digestFor(3))
+
+	// Check digest 2 first: WitnessDigest(1) below is a miss, and a miss
+	// inserts (evicting the then-oldest entry), so it must run last.
+	if familiar := ex.WitnessDigest(digestFor(2)); !familiar {
+		t.Errorf("digest 2 should have survived, it was inserted after digest 1")
+	}
+	if familiar := ex.WitnessDigest(digestFor(1)); familiar {
+		t.Errorf("digest 1 should have been evicted despite the earlier hit")
+	}
+}
+
+// TestExactWitnessDigestBatchMatchesSequentialCalls checks that batching
+// a sequence of witnesses through WitnessDigestBatch reports the same
+// familiarity and leaves the same digests evicted as issuing the same
+// sequence one WitnessDigest call at a time, since WitnessDigestBatch now
+// reuses the single-witness logic under one lock instead of delegating to
+// WitnessDigest per item.
+func TestExactWitnessDigestBatchMatchesSequentialCalls(t *testing.T) {
+	sequence := []int{1, 2, 3, 1, 4, 5, 2}
+
+	sequential := NewDejaVuExact(3)
+	var wantFamiliar []bool
+	for _, n := range sequence {
+		wantFamiliar = append(wantFamiliar, sequential.WitnessDigest(digestFor(n)))
+	}
+
+	batched := NewDejaVuExact(3)
+	digests := make([][sha256.Size]byte, len(sequence))
+	for i, n := range sequence {
+		digests[i] = digestFor(n)
+	}
+	gotFamiliar := batched.WitnessDigestBatch(digests)
+
+	for i := range sequence {
+		if gotFamiliar[i] != wantFamiliar[i] {
+			t.Errorf("index %d (digest %d): batch familiar=%v, sequential familiar=%v", i, sequence[i], gotFamiliar[i], wantFamiliar[i])
+		}
+	}
+}
+
+// TestExactZeroCapacityNeverPanics checks that a zero-capacity backend
+// (an empty slab, nothing to evict from or insert into) reports every
+// digest unfamiliar instead of panicking when it tries to evict from the
+// still-empty eviction chain.
+func TestExactZeroCapacityNeverPanics(t *testing.T) {
+	ex := NewDejaVuExact(0)
+
+	if ex.WitnessDigest(digestFor(1)) {
+		t.Errorf("digest 1: expected unfamiliar, nothing can be stored at capacity 0")
+	}
+	if ex.WitnessDigest(digestFor(1)) {
+		t.Errorf("digest 1: expected unfamiliar again, capacity 0 never remembers anything")
+	}
+}
+
+// TestExactChurnMaintainsInvariants drives random inserts past capacity
+// and checks the slab's BST/eviction-chain bookkeeping stays consistent:
+// length never exceeds capacity, and every live digest is still found by
+// search after arbitrary node deletions.
+func TestExactChurnMaintainsInvariants(t *testing.T) {
+	const capacity = 16
+	ex := NewDejaVuExact(capacity).(*exact)
+	rng := rand.New(rand.NewSource(1))
+
+	seen := make(map[[sha256.Size]byte]bool)
+	for i := 0; i < 5000; i++ {
+		n := rng.Intn(64)
+		digest := digestFor(n)
+		ex.WitnessDigest(digest)
+		seen[digest] = true
+
+		if ex.length > ex.capacity {
+			t.Fatalf("length %d exceeds capacity %d", ex.length, ex.capacity)
+		}
+
+		chainLen := int32(0)
+		for idx := ex.head; idx != nilIndex; idx = ex.nodes[idx].next {
+			chainLen++
+			if ex.search(ex.nodes[idx].digest) != idx {
+				t.Fatalf("chain node %d not found at itself via search", idx)
+			}
+		}
+		if chainLen != ex.length {
+			t.Fatalf("eviction chain length %d does not match e.length %d", chainLen, ex.length)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package dejavu
+
+import (
+	"github.com/zeebo/xxh3"
+	"lukechampine.com/blake3"
+)
+
+// NewDejaVuDeterministicBLAKE3 is equivalent to NewDejaVuDeterministic but
+// digests data with BLAKE3 instead of SHA-256.
+func NewDejaVuDeterministicBLAKE3(entrieLimit uint, opts ...Option[[32]byte]) DejaVu {
+	return newCore(entrieLimit, blake3.Sum256, hashBLAKE3, opts...)
+}
+
+// NewDejaVuDeterministicXXH128 is equivalent to NewDejaVuDeterministic but
+// digests data with XXH128 instead of SHA-256. Because XXH128 produces a
+// 16-byte digest rather than a 32-byte one, it returns DejaVuG[[16]byte]
+// rather than DejaVu.
+func NewDejaVuDeterministicXXH128(entrieLimit uint, opts ...Option[[16]byte]) DejaVuG[[16]byte] {
+	return newCore(entrieLimit, xxh128Sum, hashXXH128, opts...)
+}
+
+func xxh128Sum(data []byte) [16]byte {
+	return xxh3.Hash128(data).Bytes()
+}
@@ -0,0 +1,61 @@
+package dejavu
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"lukechampine.com/blake3"
+)
+
+// TestWithHasherOverridesWitness checks that WithHasher substitutes the
+// hash function Witness uses to digest data, without affecting
+// WitnessDigest, which already takes a digest and never hashes anything.
+func TestWithHasherOverridesWitness(t *testing.T) {
+	dv := NewDejaVuDeterministic(4, WithHasher(blake3.Sum256))
+
+	data := []byte("hello")
+	if dv.Witness(data) {
+		t.Fatalf("expected first Witness to report unfamiliar")
+	}
+	if !dv.Witness(data) {
+		t.Fatalf("expected second Witness of the same data to report familiar")
+	}
+
+	// The default SHA-256 digest of "hello" must not already be considered
+	// familiar merely because Witness was called; WithHasher should mean
+	// Witness("hello") was stored under its BLAKE3 digest, not SHA-256's.
+	sha := sha256.Sum256(data)
+	if dv.WitnessDigest(sha) {
+		t.Errorf("expected the SHA-256 digest of %q to be unfamiliar when WithHasher(blake3.Sum256) is set", data)
+	}
+}
+
+// TestNewDejaVuDeterministicBLAKE3HitsOnRepeat checks the BLAKE3
+// constructor behaves like the default SHA-256 one: unfamiliar once,
+// familiar on repeat.
+func TestNewDejaVuDeterministicBLAKE3HitsOnRepeat(t *testing.T) {
+	dv := NewDejaVuDeterministicBLAKE3(4)
+
+	data := []byte("hello")
+	if dv.Witness(data) {
+		t.Fatalf("expected first Witness to report unfamiliar")
+	}
+	if !dv.Witness(data) {
+		t.Fatalf("expected second Witness of the same data to report familiar")
+	}
+}
+
+// TestNewDejaVuDeterministicXXH128HitsOnRepeat checks the XXH128
+// constructor, which returns DejaVuG[[16]byte] rather than DejaVu, behaves
+// like the default SHA-256 one: unfamiliar once, familiar on repeat.
+func TestNewDejaVuDeterministicXXH128HitsOnRepeat(t *testing.T) {
+	dv := NewDejaVuDeterministicXXH128(4)
+
+	data := []byte("hello")
+	if dv.Witness(data) {
+		t.Fatalf("expected first Witness to report unfamiliar")
+	}
+	if !dv.Witness(data) {
+		t.Fatalf("expected second Witness of the same data to report familiar")
+	}
+}
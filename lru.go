@@ -0,0 +1,162 @@
+package dejavu
+
+import (
+	"crypto/sha256"
+	"sync"
+)
+
+type lruNode struct {
+	digest [sha256.Size]byte
+	prev   *lruNode
+	next   *lruNode
+}
+
+// lru is a DejaVu backend that evicts the least-recently-*witnessed*
+// digest once capacity is reached, rather than the oldest-inserted one
+// (compare the deterministic backend's FIFO semantics). A digest that
+// keeps being witnessed is moved to the front each time, so a hot item
+// is never forgotten merely because it was first seen long ago.
+type lru struct {
+	capacity int
+	nodes    map[[sha256.Size]byte]*lruNode
+	head     *lruNode // most recently witnessed
+	tail     *lruNode // least recently witnessed, evicted first
+	mutex    *sync.Mutex
+
+	hits, misses, evictions uint64 // Stats counters
+}
+
+// NewDejaVuLRU creates a DejaVu memory that remembers up to entrieLimit
+// digests, evicting the least-recently-witnessed digest to make room for
+// a new one.
+func NewDejaVuLRU(entrieLimit uint) DejaVu {
+	return &lru{
+		capacity: int(entrieLimit),
+		nodes:    make(map[[sha256.Size]byte]*lruNode),
+		mutex:    new(sync.Mutex),
+	}
+}
+
+func (l *lru) WitnessDigest(dataDigest [sha256.Size]byte) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if node, familiar := l.nodes[dataDigest]; familiar {
+		l.moveToFront(node)
+		l.hits++
+		return true
+	}
+	l.misses++
+
+	if l.capacity == 0 {
+		// No room was ever allocated, so there is nowhere to evict from
+		// (l.tail is still nil) or insert into; stay a no-op rather than
+		// storing a node and remembering one digest until the next miss.
+		return false
+	}
+
+	if len(l.nodes) >= l.capacity {
+		l.evict()
+	}
+
+	node := &lruNode{digest: dataDigest}
+	l.pushFront(node)
+	l.nodes[dataDigest] = node
+
+	return false
+}
+
+// Stats returns a snapshot of this backend's hit/miss/eviction counters.
+func (l *lru) Stats() Stats {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return Stats{
+		Hits:      l.hits,
+		Misses:    l.misses,
+		Evictions: l.evictions,
+		Size:      uint64(len(l.nodes)),
+		Capacity:  uint64(l.capacity),
+	}
+}
+
+func (l *lru) Witness(data []byte) bool {
+	return l.WitnessDigest(sha256.Sum256(data))
+}
+
+func (l *lru) WitnessDigestBatch(digests [][sha256.Size]byte) []bool {
+	familiar := make([]bool, len(digests))
+
+	l.mutex.Lock()
+	for i, digest := range digests {
+		if node, seen := l.nodes[digest]; seen {
+			l.moveToFront(node)
+			l.hits++
+			familiar[i] = true
+			continue
+		}
+		l.misses++
+
+		if l.capacity == 0 {
+			// See the comment in WitnessDigest.
+			continue
+		}
+
+		if len(l.nodes) >= l.capacity {
+			l.evict()
+		}
+
+		node := &lruNode{digest: digest}
+		l.pushFront(node)
+		l.nodes[digest] = node
+	}
+	l.mutex.Unlock()
+
+	return familiar
+}
+
+func (l *lru) WitnessBatch(data [][]byte) []bool {
+	return l.WitnessDigestBatch(hashBatch(data, sha256.Sum256))
+}
+
+func (l *lru) pushFront(node *lruNode) {
+	node.prev = nil
+	node.next = l.head
+	if l.head != nil {
+		l.head.prev = node
+	}
+	l.head = node
+	if l.tail == nil {
+		l.tail = node
+	}
+}
+
+func (l *lru) unlink(node *lruNode) {
+	if node.prev != nil {
+		node.prev.next = node.next
+	} else {
+		l.head = node.next
+	}
+	if node.next != nil {
+		node.next.prev = node.prev
+	} else {
+		l.tail = node.prev
+	}
+}
+
+func (l *lru) moveToFront(node *lruNode) {
+	if l.head == node {
+		return
+	}
+	l.unlink(node)
+	l.pushFront(node)
+}
+
+func (l *lru) evict() {
+	if l.tail == nil {
+		return
+	}
+	oldest := l.tail
+	l.unlink(oldest)
+	delete(l.nodes, oldest.digest)
+	l.evictions++
+}
@@ -0,0 +1,103 @@
+package dejavu
+
+import "testing"
+
+// TestLRUWitnessDigestHitRefreshesEvictionOrder verifies a hit moves a
+// digest to the front of the eviction chain: re-witnessing digest 1
+// protects it from eviction, since lru evicts least-recently-witnessed
+// (unlike exact.go, which evicts strictly in insertion order).
+func TestLRUWitnessDigestHitRefreshesEvictionOrder(t *testing.T) {
+	l := NewDejaVuLRU(2).(*lru)
+
+	l.WitnessDigest(digestFor(1))
+	l.WitnessDigest(digestFor(2))
+	if familiar := l.WitnessDigest(digestFor(1)); !familiar {
+		t.Fatalf("expected digest 1 to be familiar")
+	}
+
+	// Capacity is 2; the hit above moved digest 1 to the front, so digest 2
+	// is now the least-recently-witnessed and must be evicted, not digest 1.
+	l.WitnessDigest(digestFor(3))
+
+	if familiar := l.WitnessDigest(digestFor(1)); !familiar {
+		t.Errorf("digest 1 should have survived, it was refreshed by the earlier hit")
+	}
+	if familiar := l.WitnessDigest(digestFor(2)); familiar {
+		t.Errorf("digest 2 should have been evicted, it was least recently witnessed")
+	}
+}
+
+// TestLRUWitnessDigestBatchMatchesSequentialCalls checks that batching a
+// sequence of witnesses through WitnessDigestBatch reports the same
+// familiarity as issuing the same sequence one WitnessDigest call at a
+// time.
+func TestLRUWitnessDigestBatchMatchesSequentialCalls(t *testing.T) {
+	sequence := []int{1, 2, 3, 1, 4, 5, 2}
+
+	sequential := NewDejaVuLRU(3)
+	var wantFamiliar []bool
+	for _, n := range sequence {
+		wantFamiliar = append(wantFamiliar, sequential.WitnessDigest(digestFor(n)))
+	}
+
+	batched := NewDejaVuLRU(3)
+	digests := make([][32]byte, len(sequence))
+	for i, n := range sequence {
+		digests[i] = digestFor(n)
+	}
+	gotFamiliar := batched.WitnessDigestBatch(digests)
+
+	for i := range sequence {
+		if gotFamiliar[i] != wantFamiliar[i] {
+			t.Errorf("index %d (digest %d): batch familiar=%v, sequential familiar=%v", i, sequence[i], gotFamiliar[i], wantFamiliar[i])
+		}
+	}
+}
+
+// TestLRUZeroCapacityNeverPanics checks that a zero-capacity backend (no
+// room to evict from or insert into) reports every digest unfamiliar
+// instead of panicking, in both WitnessDigest and WitnessDigestBatch.
+func TestLRUZeroCapacityNeverPanics(t *testing.T) {
+	l := NewDejaVuLRU(0)
+
+	if l.WitnessDigest(digestFor(1)) {
+		t.Errorf("digest 1: expected unfamiliar, nothing can be stored at capacity 0")
+	}
+	if l.WitnessDigest(digestFor(1)) {
+		t.Errorf("digest 1: expected unfamiliar again, capacity 0 never remembers anything")
+	}
+
+	batch := NewDejaVuLRU(0)
+	familiar := batch.WitnessDigestBatch([][32]byte{digestFor(2), digestFor(2)})
+	if familiar[0] || familiar[1] {
+		t.Errorf("WitnessDigestBatch = %v, want all unfamiliar at capacity 0", familiar)
+	}
+}
+
+// TestLRUStatsReflectsWitnessActivity checks that Stats' hit/miss/
+// eviction/size/capacity fields track actual Witness activity.
+func TestLRUStatsReflectsWitnessActivity(t *testing.T) {
+	dv := NewDejaVuLRU(2)
+
+	dv.WitnessDigest(digestFor(1))
+	dv.WitnessDigest(digestFor(2))
+	dv.WitnessDigest(digestFor(1)) // hit
+	dv.WitnessDigest(digestFor(3)) // evicts digest 2
+
+	stats := dv.(StatsProvider).Stats()
+	if stats.Hits != 1 {
+		t.Errorf("Hits = %d, want 1", stats.Hits)
+	}
+	if stats.Misses != 3 {
+		t.Errorf("Misses = %d, want 3", stats.Misses)
+	}
+	if stats.Evictions != 1 {
+		t.Errorf("Evictions = %d, want 1", stats.Evictions)
+	}
+	if stats.Size != 2 {
+		t.Errorf("Size = %d, want 2", stats.Size)
+	}
+	if stats.Capacity != 2 {
+		t.Errorf("Capacity = %d, want 2", stats.Capacity)
+	}
+}
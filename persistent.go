@@ -0,0 +1,322 @@
+package dejavu
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"sync"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	digestsBucket = []byte("digests") // digest -> sequence number (8 bytes, big endian)
+	orderBucket   = []byte("order")   // sequence number -> digest (FIFO insertion order)
+	metaBucket    = []byte("meta")    // book-keeping: next/oldest sequence number, live entry count
+)
+
+var (
+	nextSeqKey   = []byte("next")
+	oldestSeqKey = []byte("oldest")
+	countKey     = []byte("count") // live entry count, tracked incrementally so Stats/eviction are O(1)
+)
+
+// Closer is implemented by DejaVu backends that hold external resources
+// (such as an on-disk store) which must be flushed and released when the
+// caller is done with them. Use a type assertion to reach it:
+//
+//	if c, ok := dv.(dejavu.Closer); ok {
+//		c.Close()
+//	}
+type Closer interface {
+	Close() error
+}
+
+// ErrorProvider is implemented by DejaVu backends whose writes can fail
+// (such as persistent, which can hit a full disk or I/O error) and that
+// record the most recently encountered error rather than widening every
+// DejaVu method's signature with a return value. Use a type assertion to
+// reach it:
+//
+//	if ep, ok := dv.(dejavu.ErrorProvider); ok {
+//		if err := ep.Err(); err != nil {
+//			// the last Witness/WitnessDigest call may not have persisted
+//		}
+//	}
+type ErrorProvider interface {
+	Err() error
+}
+
+// persistent is a DejaVu backend that stores witnessed digests in a bbolt
+// database so memory survives process restarts. It keeps the same FIFO
+// entrieLimit semantics as the deterministic backend, tracking insertion
+// order in a separate bucket so the oldest digest can be evicted once the
+// limit is reached. As with exact, an entrieLimit of 0 means nothing can
+// ever be stored: every digest is reported unfamiliar and nothing is
+// persisted.
+type persistent struct {
+	db         *bolt.DB
+	entryLimit uint
+	mutex      *sync.Mutex
+
+	hits, misses, evictions uint64 // Stats counters
+	lastErr                 error  // set by WitnessDigest/WitnessDigestBatch; see ErrorProvider
+}
+
+// NewDejaVuPersistent creates a DejaVu memory backed by a bbolt database at
+// path, remembering at most entrieLimit digests across process restarts.
+// Callers should invoke Close (see the Closer interface) when finished so
+// the database is flushed cleanly.
+func NewDejaVuPersistent(path string, entrieLimit uint) (DejaVu, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{digestsBucket, orderBucket, metaBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+
+		// Back-fill the count for a database written before it was tracked
+		// incrementally; this one-time bucket scan only runs here, at Open,
+		// never on the WitnessDigest/WitnessDigestBatch hot path.
+		meta := tx.Bucket(metaBucket)
+		if meta.Get(countKey) == nil {
+			writeCount(meta, uint64(tx.Bucket(digestsBucket).Stats().KeyN))
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &persistent{
+		db:         db,
+		entryLimit: entrieLimit,
+		mutex:      new(sync.Mutex),
+	}, nil
+}
+
+// Close flushes and releases the underlying database.
+func (p *persistent) Close() error {
+	return p.db.Close()
+}
+
+// Err returns the error from the most recent WitnessDigest or
+// WitnessDigestBatch call, if any. See ErrorProvider.
+func (p *persistent) Err() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.lastErr
+}
+
+func (p *persistent) WitnessDigest(dataDigest [sha256.Size]byte) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.entryLimit == 0 {
+		p.misses++
+		return false
+	}
+
+	var familiar bool
+
+	// Batch only amortizes its fsync cost across concurrent callers landing
+	// in the same MaxBatchDelay window; since p.mutex already serializes
+	// every call here, each one would just pay that delay alone. Update
+	// commits immediately instead, the same as WitnessDigestBatch does.
+	p.lastErr = p.db.Update(func(tx *bolt.Tx) error {
+		digests := tx.Bucket(digestsBucket)
+		order := tx.Bucket(orderBucket)
+		meta := tx.Bucket(metaBucket)
+
+		var oldSeq []byte
+		if v := digests.Get(dataDigest[:]); v != nil {
+			familiar = true
+			oldSeq = append([]byte(nil), v...) // copy: v is invalidated by the Put/Delete below
+		}
+
+		seq := nextSeq(meta)
+		digests.Put(dataDigest[:], seq)
+		order.Put(seq, dataDigest[:])
+		meta.Put(nextSeqKey, incr(seq))
+
+		count := readCount(meta)
+		// Re-witnessing a known digest moves it to a new seq; without this
+		// the old order[oldSeq] entry lingers and evictOldest eventually
+		// deletes the digest's fresh mapping through it.
+		if familiar {
+			order.Delete(oldSeq)
+		} else {
+			count++
+		}
+
+		var evicted uint64
+		evicted, count = evictOldest(digests, order, meta, p.entryLimit, count)
+		writeCount(meta, count)
+		p.evictions += evicted
+		return nil
+	})
+
+	if familiar {
+		p.hits++
+	} else {
+		p.misses++
+	}
+
+	return familiar
+}
+
+// Stats returns a snapshot of this backend's hit/miss/eviction counters.
+func (p *persistent) Stats() Stats {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	var size uint64
+	p.db.View(func(tx *bolt.Tx) error {
+		size = readCount(tx.Bucket(metaBucket))
+		return nil
+	})
+
+	return Stats{
+		Hits:      p.hits,
+		Misses:    p.misses,
+		Evictions: p.evictions,
+		Size:      size,
+		Capacity:  uint64(p.entryLimit),
+	}
+}
+
+func (p *persistent) Witness(data []byte) bool {
+	return p.WitnessDigest(sha256.Sum256(data))
+}
+
+func (p *persistent) WitnessDigestBatch(digests [][sha256.Size]byte) []bool {
+	familiar := make([]bool, len(digests))
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.entryLimit == 0 {
+		p.misses += uint64(len(digests))
+		return familiar
+	}
+
+	// A single transaction for the whole batch, rather than one bolt.Batch
+	// call per digest, is what actually amortizes the fsync overhead here.
+	p.lastErr = p.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(digestsBucket)
+		order := tx.Bucket(orderBucket)
+		meta := tx.Bucket(metaBucket)
+
+		count := readCount(meta)
+		for i, digest := range digests {
+			var oldSeq []byte
+			if v := bucket.Get(digest[:]); v != nil {
+				familiar[i] = true
+				oldSeq = append([]byte(nil), v...) // copy: v is invalidated by the Put/Delete below
+			}
+			if familiar[i] {
+				p.hits++
+			} else {
+				p.misses++
+			}
+
+			seq := nextSeq(meta)
+			bucket.Put(digest[:], seq)
+			order.Put(seq, digest[:])
+			meta.Put(nextSeqKey, incr(seq))
+
+			// See the comment in WitnessDigest: re-witnessing a known digest
+			// must drop its old order entry or evictOldest will eventually
+			// walk it and delete the digest's fresh mapping.
+			if familiar[i] {
+				order.Delete(oldSeq)
+			} else {
+				count++
+			}
+
+			// Evict per digest, as WitnessDigest does, so a digest pushed out
+			// earlier in the batch is reported unfamiliar if it comes back.
+			var evicted uint64
+			evicted, count = evictOldest(bucket, order, meta, p.entryLimit, count)
+			p.evictions += evicted
+		}
+
+		writeCount(meta, count)
+		return nil
+	})
+
+	return familiar
+}
+
+func (p *persistent) WitnessBatch(data [][]byte) []bool {
+	return p.WitnessDigestBatch(hashBatch(data, sha256.Sum256))
+}
+
+// nextSeq returns the next insertion-order sequence number, starting at 0.
+func nextSeq(meta *bolt.Bucket) []byte {
+	v := meta.Get(nextSeqKey)
+	if v == nil {
+		return make([]byte, 8)
+	}
+	return v
+}
+
+func incr(seq []byte) []byte {
+	next := make([]byte, 8)
+	binary.BigEndian.PutUint64(next, binary.BigEndian.Uint64(seq)+1)
+	return next
+}
+
+// readCount returns the live entry count tracked in meta, 0 if unset.
+func readCount(meta *bolt.Bucket) uint64 {
+	v := meta.Get(countKey)
+	if v == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(v)
+}
+
+// writeCount stores the live entry count in meta.
+func writeCount(meta *bolt.Bucket, n uint64) {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, n)
+	meta.Put(countKey, b)
+}
+
+// evictOldest trims the order bucket down to limit entries, removing the
+// corresponding digest lookup entries along the way. count is the live
+// entry count going in; evictOldest returns how many entries were evicted
+// and the live entry count coming out, which the caller must persist via
+// writeCount. Unlike the old approach of comparing order.Stats().KeyN
+// (which walks every page in the bucket) against limit, this keeps
+// WitnessDigest/WitnessDigestBatch O(1) in the current bucket size.
+// Callers never invoke this with limit == 0: WitnessDigest and
+// WitnessDigestBatch both short-circuit before starting a transaction in
+// that case, since a limit of 0 means nothing is ever stored.
+func evictOldest(digests, order, meta *bolt.Bucket, limit uint, count uint64) (evicted, newCount uint64) {
+	oldest := meta.Get(oldestSeqKey)
+	if oldest == nil {
+		oldest = make([]byte, 8)
+	}
+
+	next := binary.BigEndian.Uint64(nextSeq(meta))
+	for count > uint64(limit) && binary.BigEndian.Uint64(oldest) < next {
+		// A re-witness drops the digest's old order entry, leaving a gap in
+		// the sequence numbers; skip it rather than stopping there.
+		if digest := order.Get(oldest); digest != nil {
+			digests.Delete(digest)
+			order.Delete(oldest)
+			evicted++
+			count--
+		}
+		oldest = incr(oldest)
+	}
+
+	meta.Put(oldestSeqKey, oldest)
+	return evicted, count
+}
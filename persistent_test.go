@@ -0,0 +1,82 @@
+package dejavu
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func newTestPersistent(t *testing.T, entrieLimit uint) *persistent {
+	t.Helper()
+	dv, err := NewDejaVuPersistent(filepath.Join(t.TempDir(), "dejavu.db"), entrieLimit)
+	if err != nil {
+		t.Fatalf("NewDejaVuPersistent: %v", err)
+	}
+	p := dv.(*persistent)
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+// TestPersistentReWitnessDoesNotLoseEntries checks that re-witnessing an
+// already-known digest doesn't leave a stale order bucket entry behind:
+// with entrieLimit well above the number of unique digests ever witnessed,
+// none of them should ever be evicted, no matter how many times any one
+// of them is re-witnessed.
+func TestPersistentReWitnessDoesNotLoseEntries(t *testing.T) {
+	p := newTestPersistent(t, 3)
+
+	p.WitnessDigest(digestFor(1))
+	p.WitnessDigest(digestFor(2))
+	p.WitnessDigest(digestFor(3))
+	p.WitnessDigest(digestFor(1))
+	p.WitnessDigest(digestFor(1))
+
+	if stats := p.Stats(); stats.Size != 3 {
+		t.Fatalf("Size = %d, want 3 (digests 1, 2 and 3 are all within the limit)", stats.Size)
+	}
+	for _, n := range []int{1, 2, 3} {
+		if !p.WitnessDigest(digestFor(n)) {
+			t.Errorf("digest %d: expected familiar, only 3 unique digests were ever witnessed", n)
+		}
+	}
+}
+
+// TestPersistentEvictsOldestPastLimit checks that the count tracked in the
+// meta bucket (rather than a live order.Stats().KeyN scan) still evicts at
+// the right point: once more than entrieLimit unique digests have been
+// witnessed, the oldest one is forgotten and Stats never reports more than
+// the limit.
+func TestPersistentEvictsOldestPastLimit(t *testing.T) {
+	p := newTestPersistent(t, 2)
+
+	p.WitnessDigest(digestFor(1))
+	p.WitnessDigest(digestFor(2))
+	p.WitnessDigest(digestFor(3))
+
+	if stats := p.Stats(); stats.Size != 2 || stats.Evictions != 1 {
+		t.Fatalf("Stats = %+v, want Size 2 and Evictions 1", stats)
+	}
+	if p.WitnessDigest(digestFor(1)) {
+		t.Errorf("digest 1: expected unfamiliar, it should have been evicted")
+	}
+	if !p.WitnessDigest(digestFor(3)) {
+		t.Errorf("digest 3: expected familiar, it was the most recently witnessed")
+	}
+}
+
+// TestPersistentZeroLimitStoresNothing checks that entrieLimit 0 means
+// nothing is ever remembered, the same convention exact.go uses for
+// capacity 0: every digest is reported unfamiliar and Stats never reports
+// a nonzero size.
+func TestPersistentZeroLimitStoresNothing(t *testing.T) {
+	p := newTestPersistent(t, 0)
+
+	if p.WitnessDigest(digestFor(1)) {
+		t.Errorf("digest 1: expected unfamiliar, nothing can be stored at limit 0")
+	}
+	if p.WitnessDigest(digestFor(1)) {
+		t.Errorf("digest 1: expected unfamiliar again, limit 0 never remembers anything")
+	}
+	if stats := p.Stats(); stats.Size != 0 {
+		t.Errorf("Size = %d, want 0", stats.Size)
+	}
+}
@@ -0,0 +1,278 @@
+package dejavu
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sync"
+
+	"lukechampine.com/blake3"
+)
+
+// snapshotVersion is the format version written to every snapshot header.
+// Bump it, and add a case to Restore, whenever the on-disk layout changes
+// so that older snapshots keep being readable (or fail loudly instead of
+// being silently misparsed) by newer versions of this package.
+const snapshotVersion = 1
+
+// backendID identifies which concrete backend a snapshot was taken from.
+type backendID uint8
+
+const (
+	backendDeterministic backendID = iota
+	backendExact
+	backendLRU
+)
+
+// hashID identifies which hash function a deterministic backend's digests
+// were computed with, so Restore can reconstruct an equivalent hasher.
+type hashID uint8
+
+const (
+	hashSHA256 hashID = iota
+	hashBLAKE3
+	hashXXH128
+	hashCustom // set via WithHasher; Restore cannot reconstruct an arbitrary hasher
+)
+
+// Snapshot serializes the deterministic backend's ring buffer and
+// metadata to w: a format version, backend and hash-algorithm identifiers,
+// capacity, ring index, and the digest -> slot lookup, followed by a CRC.
+func (c *core[D]) Snapshot(w io.Writer) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// Restore only knows how to reconstruct a hasher for hashSHA256 and
+	// hashBLAKE3 (see restoreDeterministic); reject anything else up front
+	// rather than only discovering it while iterating c.lookup, where an
+	// empty cache would let an unrestorable snapshot succeed.
+	switch c.hashAlgo {
+	case hashSHA256, hashBLAKE3:
+	default:
+		return fmt.Errorf("dejavu: Snapshot cannot restore hash algorithm id %d", c.hashAlgo)
+	}
+
+	var body bytes.Buffer
+	body.WriteByte(snapshotVersion)
+	body.WriteByte(byte(backendDeterministic))
+	body.WriteByte(byte(c.hashAlgo))
+
+	writeUint32(&body, uint32(c.size))
+	writeUint32(&body, uint32(c.index))
+	writeUint32(&body, uint32(len(c.lookup)))
+
+	for digest, idx := range c.lookup {
+		raw, ok := any(digest).([32]byte)
+		if !ok {
+			return fmt.Errorf("dejavu: Snapshot only supports 32-byte digests, got %T", digest)
+		}
+		body.Write(raw[:])
+		writeUint32(&body, uint32(idx))
+	}
+
+	return writeWithCRC(w, body.Bytes())
+}
+
+// Snapshot serializes the exact backend's capacity and live digests, in
+// FIFO insertion order, to w.
+func (e *exact) Snapshot(w io.Writer) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	var body bytes.Buffer
+	body.WriteByte(snapshotVersion)
+	body.WriteByte(byte(backendExact))
+	body.WriteByte(byte(hashSHA256))
+
+	writeUint32(&body, uint32(e.capacity))
+	writeUint32(&body, uint32(e.length))
+
+	for idx := e.head; idx != nilIndex; idx = e.nodes[idx].next {
+		body.Write(e.nodes[idx].digest[:])
+	}
+
+	return writeWithCRC(w, body.Bytes())
+}
+
+// Snapshot serializes the LRU backend's capacity and live digests, ordered
+// least- to most-recently witnessed, to w.
+func (l *lru) Snapshot(w io.Writer) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	var body bytes.Buffer
+	body.WriteByte(snapshotVersion)
+	body.WriteByte(byte(backendLRU))
+	body.WriteByte(byte(hashSHA256))
+
+	writeUint32(&body, uint32(l.capacity))
+	writeUint32(&body, uint32(len(l.nodes)))
+
+	for node := l.tail; node != nil; node = node.prev {
+		body.Write(node.digest[:])
+	}
+
+	return writeWithCRC(w, body.Bytes())
+}
+
+// Restore reconstructs a DejaVu backend from a snapshot written by
+// Snapshot, letting CLI tools and rolling deploys hand off warm caches
+// between processes without paying for a full KV store.
+func Restore(r io.Reader) (DejaVu, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) < 4 {
+		return nil, errors.New("dejavu: snapshot too short")
+	}
+
+	payload, crcBytes := raw[:len(raw)-4], raw[len(raw)-4:]
+	if binary.BigEndian.Uint32(crcBytes) != crc32.ChecksumIEEE(payload) {
+		return nil, errors.New("dejavu: snapshot checksum mismatch")
+	}
+	if len(payload) < 3 {
+		return nil, errors.New("dejavu: snapshot header truncated")
+	}
+
+	version, backend, algo := payload[0], backendID(payload[1]), hashID(payload[2])
+	if version != snapshotVersion {
+		return nil, fmt.Errorf("dejavu: unsupported snapshot version %d", version)
+	}
+	body := bytes.NewReader(payload[3:])
+
+	switch backend {
+	case backendDeterministic:
+		return restoreDeterministic(body, algo)
+	case backendExact:
+		return restoreExact(body)
+	case backendLRU:
+		return restoreLRU(body)
+	default:
+		return nil, fmt.Errorf("dejavu: unknown snapshot backend id %d", backend)
+	}
+}
+
+func restoreDeterministic(body *bytes.Reader, algo hashID) (DejaVu, error) {
+	size, err := readUint32(body)
+	if err != nil {
+		return nil, err
+	}
+	index, err := readUint32(body)
+	if err != nil {
+		return nil, err
+	}
+	count, err := readUint32(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var hasher func([]byte) [sha256.Size]byte
+	switch algo {
+	case hashSHA256:
+		hasher = sha256.Sum256
+	case hashBLAKE3:
+		hasher = blake3.Sum256
+	default:
+		return nil, fmt.Errorf("dejavu: cannot restore snapshot with hash algorithm id %d", algo)
+	}
+
+	c := &core[[sha256.Size]byte]{
+		buffer:   make([][sha256.Size]byte, size),
+		size:     int(size),
+		index:    int(index),
+		lookup:   make(map[[sha256.Size]byte]int, count),
+		hasher:   hasher,
+		hashAlgo: algo,
+		mutex:    new(sync.Mutex),
+	}
+
+	for i := uint32(0); i < count; i++ {
+		var digest [sha256.Size]byte
+		if _, err := io.ReadFull(body, digest[:]); err != nil {
+			return nil, err
+		}
+		idx, err := readUint32(body)
+		if err != nil {
+			return nil, err
+		}
+		c.lookup[digest] = int(idx)
+		if int(idx) < len(c.buffer) {
+			c.buffer[idx] = digest
+		}
+	}
+
+	return c, nil
+}
+
+func restoreExact(body *bytes.Reader) (DejaVu, error) {
+	capacity, err := readUint32(body)
+	if err != nil {
+		return nil, err
+	}
+	count, err := readUint32(body)
+	if err != nil {
+		return nil, err
+	}
+
+	dv := NewDejaVuExact(uint(capacity))
+	for i := uint32(0); i < count; i++ {
+		var digest [sha256.Size]byte
+		if _, err := io.ReadFull(body, digest[:]); err != nil {
+			return nil, err
+		}
+		dv.WitnessDigest(digest)
+	}
+
+	return dv, nil
+}
+
+func restoreLRU(body *bytes.Reader) (DejaVu, error) {
+	capacity, err := readUint32(body)
+	if err != nil {
+		return nil, err
+	}
+	count, err := readUint32(body)
+	if err != nil {
+		return nil, err
+	}
+
+	dv := NewDejaVuLRU(uint(capacity))
+	for i := uint32(0); i < count; i++ {
+		var digest [sha256.Size]byte
+		if _, err := io.ReadFull(body, digest[:]); err != nil {
+			return nil, err
+		}
+		dv.WitnessDigest(digest)
+	}
+
+	return dv, nil
+}
+
+func writeUint32(w *bytes.Buffer, v uint32) {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+	w.Write(buf[:])
+}
+
+func readUint32(r io.Reader) (uint32, error) {
+	var buf [4]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(buf[:]), nil
+}
+
+func writeWithCRC(w io.Writer, payload []byte) error {
+	if _, err := w.Write(payload); err != nil {
+		return err
+	}
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], crc32.ChecksumIEEE(payload))
+	_, err := w.Write(buf[:])
+	return err
+}
@@ -0,0 +1,131 @@
+package dejavu
+
+import (
+	"bytes"
+	"testing"
+)
+
+func witnessAll(dv DejaVu, ns []int) {
+	for _, n := range ns {
+		dv.WitnessDigest(digestFor(n))
+	}
+}
+
+// TestSnapshotRestoreRoundTrip checks that each backend's live digests
+// survive a Snapshot/Restore round trip: everything witnessed before the
+// snapshot is still reported familiar afterwards, and nothing else is.
+func TestSnapshotRestoreRoundTrip(t *testing.T) {
+	cases := []struct {
+		name string
+		new  func() DejaVu
+	}{
+		{"deterministic", func() DejaVu { return NewDejaVuDeterministic(4) }},
+		{"exact", func() DejaVu { return NewDejaVuExact(4) }},
+		{"lru", func() DejaVu { return NewDejaVuLRU(4) }},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dv := c.new()
+			witnessAll(dv, []int{1, 2, 3, 4})
+
+			var buf bytes.Buffer
+			if err := snapshotOf(t, dv, &buf); err != nil {
+				t.Fatalf("Snapshot: %v", err)
+			}
+
+			restored, err := Restore(&buf)
+			if err != nil {
+				t.Fatalf("Restore: %v", err)
+			}
+
+			for _, n := range []int{1, 2, 3, 4} {
+				if !restored.WitnessDigest(digestFor(n)) {
+					t.Errorf("digest %d: expected familiar after restore", n)
+				}
+			}
+			if restored.WitnessDigest(digestFor(99)) {
+				t.Errorf("digest 99: expected unfamiliar after restore")
+			}
+		})
+	}
+}
+
+// snapshotOf calls the Snapshot method implemented by each concrete
+// backend type; DejaVu itself does not expose Snapshot since only some
+// backends support it.
+func snapshotOf(t *testing.T, dv DejaVu, buf *bytes.Buffer) error {
+	t.Helper()
+	switch b := dv.(type) {
+	case *core[[32]byte]:
+		return b.Snapshot(buf)
+	case *exact:
+		return b.Snapshot(buf)
+	case *lru:
+		return b.Snapshot(buf)
+	default:
+		t.Fatalf("unsupported backend type %T", dv)
+		return nil
+	}
+}
+
+// TestSnapshotRejectsUnrestorableHashAlgo checks that Snapshot fails an
+// XXH128 backend up front even when it is empty, since restoreDeterministic
+// has no case for hashXXH128 and would otherwise fail later at Restore
+// time with no way for the caller to have known sooner.
+func TestSnapshotRejectsUnrestorableHashAlgo(t *testing.T) {
+	dv := NewDejaVuDeterministicXXH128(4)
+
+	var buf bytes.Buffer
+	if err := dv.(*core[[16]byte]).Snapshot(&buf); err == nil {
+		t.Fatal("expected Snapshot to reject an empty XXH128 backend")
+	}
+}
+
+// TestRestoreRejectsCorruptSnapshot checks that a flipped payload byte is
+// caught by the CRC rather than silently misparsed.
+func TestRestoreRejectsCorruptSnapshot(t *testing.T) {
+	dv := NewDejaVuExact(4)
+	witnessAll(dv, []int{1, 2, 3})
+
+	var buf bytes.Buffer
+	if err := dv.(*exact).Snapshot(&buf); err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+
+	corrupt := buf.Bytes()
+	corrupt[0] ^= 0xFF
+
+	if _, err := Restore(bytes.NewReader(corrupt)); err == nil {
+		t.Fatal("expected Restore to reject a corrupted snapshot")
+	}
+}
+
+// TestRestoreRejectsUnsupportedVersion checks that a snapshot claiming a
+// future format version fails loudly instead of being misparsed.
+func TestRestoreRejectsUnsupportedVersion(t *testing.T) {
+	dv := NewDejaVuLRU(4)
+	witnessAll(dv, []int{1, 2})
+
+	var buf bytes.Buffer
+	if err := dv.(*lru).Snapshot(&buf); err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+
+	raw := buf.Bytes()
+	raw[0] = snapshotVersion + 1
+	fixed := writeWithCRCForTest(t, raw[:len(raw)-4])
+
+	if _, err := Restore(bytes.NewReader(fixed)); err == nil {
+		t.Fatal("expected Restore to reject an unsupported snapshot version")
+	}
+}
+
+func writeWithCRCForTest(t *testing.T, payload []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := writeWithCRC(&buf, payload); err != nil {
+		t.Fatalf("writeWithCRC: %v", err)
+	}
+	return buf.Bytes()
+}
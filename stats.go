@@ -0,0 +1,83 @@
+package dejavu
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Stats is a point-in-time snapshot of a backend's usage, meant for
+// observability: alerting on cache saturation or eviction storms when
+// dejavu is run as a long-lived deduplication filter in a pipeline.
+type Stats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Size      uint64
+	Capacity  uint64
+
+	// FalsePositiveRate estimates the probability that Witness incorrectly
+	// returns true for a never-before-seen digest. Every backend in this
+	// package is exact (no false positives), so this always reports 0; it
+	// is reserved for a future probabilistic backend.
+	FalsePositiveRate float64
+}
+
+// StatsProvider is implemented by DejaVu backends that track hit/miss/
+// eviction counters. Use a type assertion to reach it:
+//
+//	if s, ok := dv.(dejavu.StatsProvider); ok {
+//		stats := s.Stats()
+//	}
+type StatsProvider interface {
+	Stats() Stats
+}
+
+// WithPrometheusRegisterer registers counters and gauges mirroring Stats
+// under registerer, prefixed with namespace. It is an Option for the
+// deterministic backend family (NewDejaVuDeterministic and friends), since
+// those are the backends that accept functional options.
+func WithPrometheusRegisterer[D comparable](registerer prometheus.Registerer, namespace string) Option[D] {
+	return func(c *core[D]) {
+		factory := promauto.With(registerer)
+
+		factory.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "dejavu_hits_total",
+			Help:      "Total number of Witness/WitnessDigest calls that recognized a previously seen digest.",
+		}, func() float64 {
+			return float64(c.Stats().Hits)
+		})
+
+		factory.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "dejavu_misses_total",
+			Help:      "Total number of Witness/WitnessDigest calls for a digest not previously seen.",
+		}, func() float64 {
+			return float64(c.Stats().Misses)
+		})
+
+		factory.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "dejavu_evictions_total",
+			Help:      "Total number of digests forgotten to make room for new ones.",
+		}, func() float64 {
+			return float64(c.Stats().Evictions)
+		})
+
+		factory.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "dejavu_size",
+			Help:      "Number of digests currently held in memory.",
+		}, func() float64 {
+			return float64(c.Stats().Size)
+		})
+
+		factory.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "dejavu_capacity",
+			Help:      "Maximum number of digests the backend will hold in memory.",
+		}, func() float64 {
+			return float64(c.Stats().Capacity)
+		})
+	}
+}
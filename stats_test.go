@@ -0,0 +1,77 @@
+package dejavu
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// TestStatsReflectsWitnessActivity checks that Stats' hit/miss/eviction/
+// size/capacity fields track actual Witness activity on the exact
+// backend.
+func TestStatsReflectsWitnessActivity(t *testing.T) {
+	dv := NewDejaVuExact(2)
+
+	dv.WitnessDigest(digestFor(1))
+	dv.WitnessDigest(digestFor(2))
+	dv.WitnessDigest(digestFor(1)) // hit
+	dv.WitnessDigest(digestFor(3)) // evicts digest 1
+
+	stats := dv.(StatsProvider).Stats()
+	if stats.Hits != 1 {
+		t.Errorf("Hits = %d, want 1", stats.Hits)
+	}
+	if stats.Misses != 3 {
+		t.Errorf("Misses = %d, want 3", stats.Misses)
+	}
+	if stats.Evictions != 1 {
+		t.Errorf("Evictions = %d, want 1", stats.Evictions)
+	}
+	if stats.Size != 2 {
+		t.Errorf("Size = %d, want 2", stats.Size)
+	}
+	if stats.Capacity != 2 {
+		t.Errorf("Capacity = %d, want 2", stats.Capacity)
+	}
+}
+
+// TestWithPrometheusRegistererExposesStats checks that the counters and
+// gauges registered by WithPrometheusRegisterer mirror Stats.
+func TestWithPrometheusRegistererExposesStats(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	dv := NewDejaVuDeterministic(2, WithPrometheusRegisterer[[32]byte](registry, "test"))
+
+	dv.WitnessDigest(digestFor(1))
+	dv.WitnessDigest(digestFor(1)) // hit
+	dv.WitnessDigest(digestFor(2))
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+
+	values := make(map[string]float64)
+	for _, family := range families {
+		m := family.GetMetric()[0]
+		switch {
+		case m.Counter != nil:
+			values[family.GetName()] = m.Counter.GetValue()
+		case m.Gauge != nil:
+			values[family.GetName()] = m.Gauge.GetValue()
+		}
+	}
+
+	want := map[string]float64{
+		"test_dejavu_hits_total":   1,
+		"test_dejavu_misses_total": 2,
+		"test_dejavu_size":         2,
+		"test_dejavu_capacity":     2,
+	}
+	for name, wantValue := range want {
+		if got, ok := values[name]; !ok {
+			t.Errorf("metric %s not registered", name)
+		} else if got != wantValue {
+			t.Errorf("metric %s = %v, want %v", name, got, wantValue)
+		}
+	}
+}